@@ -0,0 +1,114 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrUser and ErrInfra are sentinels a Stage's Action can wrap its own
+// error with (e.g. fmt.Errorf("bad config: %w", stages.ErrUser)) so that
+// library consumers can tell user-caused failures apart from
+// infrastructure-caused ones with errors.Is, without the package having to
+// know anything about the specific error types a consumer uses.
+var (
+	ErrUser  = errors.New("stages: user error")
+	ErrInfra = errors.New("stages: infrastructure error")
+)
+
+// StageError identifies which stage in a Runner's pipeline failed, along
+// with enough context - elapsed time, a tail of its captured log, and a
+// stack trace captured at the point of failure - to debug it after the
+// fact without re-running the pipeline.
+type StageError struct {
+	Stage   string
+	Index   int
+	Elapsed time.Duration
+	LogTail string
+	Err     error
+
+	stack []uintptr
+}
+
+// newStageError builds a StageError and captures the current call stack.
+func newStageError(stage string, index int, elapsed time.Duration, logTail string, err error) *StageError {
+	const depth = 32
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(2, pcs)
+	return &StageError{
+		Stage:   stage,
+		Index:   index,
+		Elapsed: elapsed,
+		LogTail: logTail,
+		Err:     err,
+		stack:   pcs[:n],
+	}
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %q (index %d) failed after %s: %v", e.Stage, e.Index, e.Elapsed, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// frames resolves the captured program counters into source locations,
+// topmost (most recent) call first.
+func (e *StageError) frames() []runtime.Frame {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	framesIter := runtime.CallersFrames(e.stack)
+	var out []runtime.Frame
+	for {
+		frame, more := framesIter.Next()
+		out = append(out, frame)
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+// Report writes a one-line summary - the topmost stack frame plus the
+// failing stage's name - to stderr, and the full annotated error, its
+// cause chain, log tail, and stack trace to path (typically
+// "bubbletea-debug.log" in the working directory).
+func (e *StageError) Report(path string) error {
+	frames := e.frames()
+	top := "unknown location"
+	if len(frames) > 0 {
+		top = fmt.Sprintf("%s:%d", frames[0].File, frames[0].Line)
+	}
+	fmt.Fprintf(os.Stderr, "stage %q failed at %s: %v\n", e.Stage, top, e.Err)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "Ran at: %s\n", time.Now().UTC())
+	fmt.Fprintln(f, strings.Repeat("*", 78))
+	fmt.Fprintf(f, "Stage %q (index %d) failed after %s\n", e.Stage, e.Index, e.Elapsed)
+	fmt.Fprintln(f, strings.Repeat("*", 78))
+
+	fmt.Fprintln(f, "\nCause chain:")
+	for cur := error(e); cur != nil; cur = errors.Unwrap(cur) {
+		fmt.Fprintf(f, "  - %v\n", cur)
+	}
+
+	fmt.Fprintln(f, "\nLog tail:")
+	fmt.Fprintln(f, e.LogTail)
+
+	fmt.Fprintln(f, "\nStack trace:")
+	for _, fr := range frames {
+		fmt.Fprintf(f, "  %s\n      %s:%d\n", fr.Function, fr.File, fr.Line)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package stages
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer guarded by a mutex: a stage's Action writes
+// into it from its own goroutine via the io.Writer passed to Action, while
+// an interactive Model's log viewport (or DumpStageLog) may read it
+// concurrently from another goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns a snapshot of the buffer's current contents.
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Bytes returns a copy of the buffer's current contents.
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]byte, b.buf.Len())
+	copy(out, b.buf.Bytes())
+	return out
+}
+
+// tailLines returns the last n lines of s, so a StageError can carry a
+// manageable snippet of a stage's log instead of the whole thing.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DumpStageLog writes the captured output of the named stage to a file in
+// dir, so a user can share exactly the failing stage's log instead of a
+// dump of the whole run. The file is named "<stage>.log" and returns its
+// path.
+func (r *Runner) DumpStageLog(dir, name string) (string, error) {
+	buf := r.Log(name)
+	if buf == nil {
+		return "", fmt.Errorf("stages: no captured log for stage %q", name)
+	}
+
+	path := fmt.Sprintf("%s/%s.log", dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "stage: %s\n", name)
+	fmt.Fprintf(f, "dumped at: %s\n", time.Now().UTC().String())
+	fmt.Fprintln(f, "----------------------------------------")
+	f.Write(buf.Bytes())
+
+	return path, nil
+}
@@ -0,0 +1,530 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Model is a tea.Model that drives a Runner's stage DAG and renders the
+// progress of every stage currently in flight. Construct one with NewModel
+// and hand it to tea.NewProgram. Press 'l' to toggle a scrollable viewport
+// showing the focused stage's log.
+//
+// When a stage fails, the Model doesn't quit outright: it pauses on that
+// stage and prompts the user to retry ('r'), skip it and continue ('s'),
+// undo prior completed stages ('u'), or abort ('q'/Ctrl-C).
+type Model struct {
+	runner *Runner
+	base   context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	spinners map[string]spinner.Model
+	focus    string // name of the stage whose log the viewport shows
+
+	errs  []error
+	Error error
+
+	// awaiting is the name of a failed stage the user is being prompted
+	// about, or "" if nothing is awaiting a decision. pendingFailures queues
+	// up any other stages that failed concurrently, so each gets its own
+	// turn at the prompt once awaiting is resolved instead of being silently
+	// dropped.
+	awaiting        string
+	pendingFailures []string
+	skipVeto        string
+	dumpStatus      string
+	undoStatus      string
+
+	logView       viewport.Model
+	showLogs      bool
+	viewportSized bool
+	logTicking    bool
+}
+
+// logRefreshInterval is how often the open log viewport's content is
+// refreshed from the focused stage's buffer.
+const logRefreshInterval = 250 * time.Millisecond
+
+// logTickMsg drives the log viewport's live tail: as long as showLogs is
+// true, each tick re-reads the focused stage's buffer so a continuously
+// logging stage's output actually streams instead of showing whatever
+// snapshot was captured when the viewport was opened.
+type logTickMsg struct{}
+
+func logTickCmd() tea.Cmd {
+	return tea.Tick(logRefreshInterval, func(time.Time) tea.Msg {
+		return logTickMsg{}
+	})
+}
+
+// NewModel builds a Model that runs the given Runner's stage DAG under ctx.
+func NewModel(ctx context.Context, r *Runner) Model {
+	runCtx, cancel := context.WithCancel(ctx)
+
+	spinners := make(map[string]spinner.Model, len(r.Stages))
+	for _, s := range r.Stages {
+		sp := spinner.New()
+		sp.Spinner = spinner.Dot
+		sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+		spinners[s.Name] = sp
+	}
+
+	m := Model{
+		runner:   r,
+		base:     ctx,
+		ctx:      runCtx,
+		cancel:   cancel,
+		spinners: spinners,
+		logView:  viewport.New(0, 0),
+	}
+	// A bad graph (unknown DependsOn name, or a cycle) would otherwise leave
+	// every stage Pending forever - readyStages never finds anything to
+	// dispatch, and nothing ever cancels the run. Catch that here so Init
+	// can quit immediately instead of hanging.
+	m.Error = r.validate()
+	return m
+}
+
+// stageCompleteMsg reports that a stage's Action succeeded, or was already
+// satisfied by its IsCompleteFunc.
+type stageCompleteMsg struct {
+	Name string
+}
+
+// stageFailedMsg reports that a stage's Action returned an error.
+type stageFailedMsg struct {
+	Name string
+	Err  *StageError
+}
+
+// runStageCmd runs stage.Action (or consults IsCompleteFunc) and reports
+// the result as a stageCompleteMsg or stageFailedMsg. Bubble Tea runs each
+// returned tea.Cmd in its own goroutine, so batching several runStageCmd
+// values together is how independent stages execute concurrently.
+func (m Model) runStageCmd(stage Stage) tea.Cmd {
+	return func() tea.Msg {
+		if stage.IsCompleteFunc != nil && stage.IsCompleteFunc() {
+			return stageCompleteMsg{Name: stage.Name}
+		}
+
+		buf := &syncBuffer{}
+		m.runner.setLog(stage.Name, buf)
+
+		stageCtx := m.ctx
+		cancel := func() {}
+		if stage.Timeout > 0 {
+			stageCtx, cancel = context.WithTimeout(m.ctx, stage.Timeout)
+		}
+		start := time.Now()
+		err := stage.Action(stageCtx, buf)
+		elapsed := time.Since(start)
+		cancel()
+
+		if err == nil {
+			return stageCompleteMsg{Name: stage.Name}
+		}
+		index := m.runner.indexOf(stage.Name)
+		stageErr := newStageError(stage.Name, index, elapsed, tailLines(buf.String(), 20), err)
+		return stageFailedMsg{Name: stage.Name, Err: stageErr}
+	}
+}
+
+// readyStages returns every Pending stage whose DependsOn are all Complete.
+func (m Model) readyStages() []Stage {
+	var ready []Stage
+	for _, s := range m.runner.Stages {
+		if m.runner.State(s.Name) != StagePending {
+			continue
+		}
+		blocked := false
+		for _, dep := range s.DependsOn {
+			if m.runner.State(dep) != StageComplete {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			ready = append(ready, s)
+		}
+	}
+	return ready
+}
+
+// dispatchReady marks every ready stage Running and returns the tea.Cmds
+// that will execute them.
+func (m *Model) dispatchReady() []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, s := range m.readyStages() {
+		m.runner.setState(s.Name, StageRunning)
+		if m.focus == "" {
+			m.focus = s.Name
+		}
+		cmds = append(cmds, m.runStageCmd(s))
+	}
+	return cmds
+}
+
+func (m Model) Init() tea.Cmd {
+	if m.Error != nil {
+		return tea.Quit
+	}
+	cmds := m.dispatchReady()
+	for _, sp := range m.spinners {
+		cmds = append(cmds, sp.Tick)
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.logView.Width = msg.Width
+		m.logView.Height = msg.Height / 3
+		m.viewportSized = true
+		return m, nil
+
+	case stageCompleteMsg:
+		stage := m.runner.stageByName(msg.Name)
+		stage.IsComplete = true
+		m.runner.setState(msg.Name, StageComplete)
+
+		if m.awaiting != "" {
+			// A sibling stage finished while we're prompting about a
+			// failure elsewhere; nothing to dispatch until that's resolved.
+			return m, nil
+		}
+		if m.allComplete() {
+			return m, tea.Quit
+		}
+		return m, tea.Batch(m.dispatchReady()...)
+
+	case stageFailedMsg:
+		stage := m.runner.stageByName(msg.Name)
+		stage.Error = msg.Err
+		m.runner.setState(msg.Name, StageFailed)
+		m.errs = append(m.errs, msg.Err)
+		m.cancel()
+		if m.awaiting == "" {
+			m.awaiting = msg.Name
+			m.focus = msg.Name
+			// Auto-open the log viewport on the failed stage, same as the
+			// non-interactive quit-on-failure path used to before this
+			// stage started pausing for retry/skip/undo instead.
+			return m, m.openLogs()
+		}
+		// Another failure is already awaiting a decision; queue this one
+		// so it gets its own turn at the prompt instead of being recorded
+		// in m.errs with no way to retry, skip, or even see it.
+		m.pendingFailures = append(m.pendingFailures, msg.Name)
+		if m.showLogs {
+			m.logView.SetContent(m.currentLog())
+			m.logView.GotoBottom()
+		}
+		return m, nil
+
+	case logTickMsg:
+		if !m.showLogs {
+			m.logTicking = false
+			return m, nil
+		}
+		m.logView.SetContent(m.currentLog())
+		m.logView.GotoBottom()
+		return m, logTickCmd()
+
+	case spinner.TickMsg:
+		for name, sp := range m.spinners {
+			if sp.ID() != msg.ID {
+				continue
+			}
+			var cmd tea.Cmd
+			m.spinners[name], cmd = sp.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m.abort()
+		case tea.KeyRunes:
+			key := string(msg.Runes)
+			if m.awaiting != "" {
+				switch key {
+				case "r":
+					cmd := m.retry()
+					return m, cmd
+				case "s":
+					cmd := m.skip()
+					return m, cmd
+				case "u":
+					m.undo()
+					return m, nil
+				case "d":
+					m.dumpLog()
+					return m, nil
+				case "q":
+					return m.abort()
+				}
+				return m, nil
+			}
+			switch key {
+			case "l":
+				if m.showLogs {
+					m.showLogs = false
+					return m, nil
+				}
+				return m, m.openLogs()
+			case "q":
+				return m.abort()
+			}
+		}
+	}
+
+	if m.showLogs {
+		var cmd tea.Cmd
+		m.logView, cmd = m.logView.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// abort cancels any in-flight stages, records the accumulated failures as
+// the final Error, and quits.
+func (m Model) abort() (tea.Model, tea.Cmd) {
+	m.cancel()
+	if len(m.errs) > 0 {
+		m.Error = errors.Join(m.errs...)
+	}
+	return m, tea.Quit
+}
+
+// retry re-invokes Action for the currently failed stage.
+func (m *Model) retry() tea.Cmd {
+	name := m.awaiting
+	stage := m.runner.stageByName(name)
+	m.dropError(name)
+	stage.Error = nil
+	m.dumpStatus = ""
+	m.undoStatus = ""
+	m.ctx, m.cancel = context.WithCancel(m.base)
+	m.runner.setState(name, StageRunning)
+	m.awaiting = ""
+	cmd := m.runStageCmd(*stage)
+	return tea.Batch(cmd, m.advanceAwaiting())
+}
+
+// dumpLog writes the awaiting stage's captured log to disk via
+// Runner.DumpStageLog, so it can be shared without scrolling back through
+// the whole TUI transcript. The result is shown alongside the failure
+// prompt.
+func (m *Model) dumpLog() {
+	path, err := m.runner.DumpStageLog(".", m.awaiting)
+	if err != nil {
+		m.dumpStatus = fmt.Sprintf("log dump failed: %v", err)
+		return
+	}
+	m.dumpStatus = fmt.Sprintf("wrote log to %s", path)
+}
+
+// undo rolls back every completed stage via Runner.rollback and records
+// which ones it reverted, so the failure prompt confirms what happened
+// instead of leaving the user guessing. The rolled-back stages go back to
+// StagePending, so they're redispatched like any other pending stage once
+// the failure currently awaiting a decision is retried or skipped.
+func (m *Model) undo() {
+	rolledBack := m.runner.rollback(context.Background())
+	if len(rolledBack) == 0 {
+		m.undoStatus = "nothing to undo"
+		return
+	}
+	m.undoStatus = fmt.Sprintf("rolled back: %s", strings.Join(rolledBack, ", "))
+}
+
+// skip marks the currently failed stage complete and resumes the pipeline,
+// unless the stage's SkipGuard vetoes it.
+func (m *Model) skip() tea.Cmd {
+	name := m.awaiting
+	stage := m.runner.stageByName(name)
+	if stage.SkipGuard != nil {
+		if err := stage.SkipGuard(); err != nil {
+			m.skipVeto = err.Error()
+			return nil
+		}
+	}
+	m.skipVeto = ""
+	m.dumpStatus = ""
+	m.undoStatus = ""
+	m.dropError(name)
+	stage.Error = nil
+	stage.IsComplete = true
+	m.runner.setState(name, StageComplete)
+	m.ctx, m.cancel = context.WithCancel(m.base)
+	m.awaiting = ""
+	advanceCmd := m.advanceAwaiting()
+
+	if m.awaiting != "" {
+		// Another failure is still waiting on a decision; don't dispatch
+		// anything further until that's resolved too.
+		return advanceCmd
+	}
+	if m.allComplete() {
+		return tea.Quit
+	}
+	return tea.Batch(m.dispatchReady()...)
+}
+
+// advanceAwaiting pops the next queued failure into m.awaiting, if any, so
+// a stage that failed while a sibling's failure was already being resolved
+// gets its own turn at the prompt instead of being skipped over.
+func (m *Model) advanceAwaiting() tea.Cmd {
+	if len(m.pendingFailures) == 0 {
+		return nil
+	}
+	m.awaiting, m.pendingFailures = m.pendingFailures[0], m.pendingFailures[1:]
+	m.focus = m.awaiting
+	return m.openLogs()
+}
+
+// openLogs shows the log viewport for the focused stage and, unless a
+// refresh loop is already running, starts one so the view keeps tailing
+// the stage's buffer instead of freezing on a single snapshot.
+func (m *Model) openLogs() tea.Cmd {
+	m.showLogs = true
+	m.logView.SetContent(m.currentLog())
+	m.logView.GotoBottom()
+	if m.logTicking {
+		return nil
+	}
+	m.logTicking = true
+	return logTickCmd()
+}
+
+// allComplete reports whether every stage has reached StageComplete. The
+// run should only be treated as a success once this is true - a stage that
+// finished Failed and was never retried or skipped must not be silently
+// treated as done just because nothing is Running anymore.
+func (m Model) allComplete() bool {
+	for _, s := range m.runner.Stages {
+		if m.runner.State(s.Name) != StageComplete {
+			return false
+		}
+	}
+	return true
+}
+
+// dropError removes any recorded failure for the named stage, used when a
+// failure is resolved via retry or skip.
+func (m *Model) dropError(name string) {
+	kept := m.errs[:0]
+	for _, e := range m.errs {
+		var se *StageError
+		if errors.As(e, &se) && se.Stage == name {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	m.errs = kept
+}
+
+// FailedStage returns the name of the failed stage the failure prompt is
+// about; when several stages fail concurrently, that is the earliest one not
+// yet retried or skipped. It's only meaningful once Error is non-nil.
+func (m Model) FailedStage() string {
+	return m.focus
+}
+
+// currentLog returns the captured log for the focused stage.
+func (m Model) currentLog() string {
+	buf := m.runner.Log(m.focus)
+	if buf == nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func renderCheckbox(state StageState) string {
+	switch state {
+	case StageFailed:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(" ‚ùå ")
+	case StageComplete:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Render(" ‚úÖ ")
+	default:
+		return " üî≤ "
+	}
+}
+
+func (m Model) renderWorkingStatus(s Stage, state StageState) string {
+	sb := strings.Builder{}
+	if state == StageRunning {
+		sb.WriteString(m.spinners[s.Name].View())
+	} else {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(" ")
+	sb.WriteString(s.Name)
+	return sb.String()
+}
+
+func (m Model) View() string {
+	sb := strings.Builder{}
+
+	names := make([]string, 0, len(m.spinners))
+	for _, s := range m.runner.Stages {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names) // stable ordering for the running-stages summary
+
+	running := make([]string, 0, len(names))
+	for _, n := range names {
+		if m.runner.State(n) == StageRunning {
+			running = append(running, n)
+		}
+	}
+	if len(running) > 0 {
+		sb.WriteString(fmt.Sprintf("Running: %s\n", strings.Join(running, ", ")))
+	} else {
+		sb.WriteString("Running: (none)\n")
+	}
+
+	for _, stage := range m.runner.Stages {
+		state := m.runner.State(stage.Name)
+		sb.WriteString(renderCheckbox(state) + " " + m.renderWorkingStatus(stage, state) + "\n")
+	}
+
+	if m.awaiting != "" {
+		stage := m.runner.stageByName(m.awaiting)
+		sb.WriteString(fmt.Sprintf("\nstage %q failed: %v\n", m.awaiting, stage.Error))
+		if len(m.pendingFailures) > 0 {
+			sb.WriteString(fmt.Sprintf("(%d more failure(s) waiting: %s)\n", len(m.pendingFailures), strings.Join(m.pendingFailures, ", ")))
+		}
+		if m.skipVeto != "" {
+			sb.WriteString(fmt.Sprintf("skip vetoed: %s\n", m.skipVeto))
+		}
+		if m.dumpStatus != "" {
+			sb.WriteString(m.dumpStatus + "\n")
+		}
+		if m.undoStatus != "" {
+			sb.WriteString(m.undoStatus + "\n")
+		}
+		sb.WriteString("[r]etry  [s]kip  [u]ndo completed stages  [d]ump log  [q]uit\n")
+	}
+
+	if m.showLogs && m.viewportSized {
+		sb.WriteString(fmt.Sprintf("\n--- %s logs (l to hide) ---\n", m.focus))
+		sb.WriteString(m.logView.View())
+	} else {
+		sb.WriteString("\n(press l for logs)\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,233 @@
+package stages
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func keyRune(r rune) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+}
+
+// runCmd runs cmd and, if it produced a tea.BatchMsg (e.g. retry batching
+// the re-run alongside a log-viewport refresh), returns the first message
+// in the batch that isn't a logTickMsg.
+func runCmd(t *testing.T, cmd tea.Cmd) tea.Msg {
+	t.Helper()
+	msg := cmd()
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		return msg
+	}
+	for _, c := range batch {
+		if m := c(); !isLogTick(m) {
+			return m
+		}
+	}
+	t.Fatal("batch contained no message other than logTickMsg")
+	return nil
+}
+
+func isLogTick(msg tea.Msg) bool {
+	_, ok := msg.(logTickMsg)
+	return ok
+}
+
+func TestModelQueuesConcurrentFailures(t *testing.T) {
+	r := NewRunner([]Stage{
+		{Name: "A", Action: func(ctx context.Context, w io.Writer) error { return nil }},
+		{Name: "B", Action: func(ctx context.Context, w io.Writer) error { return nil }},
+	})
+	m := NewModel(context.Background(), r)
+
+	errA := &StageError{Stage: "A"}
+	errB := &StageError{Stage: "B"}
+
+	updated, _ := m.Update(stageFailedMsg{Name: "A", Err: errA})
+	mm := updated.(Model)
+	if mm.awaiting != "A" {
+		t.Fatalf("awaiting = %q, want %q", mm.awaiting, "A")
+	}
+
+	updated, _ = mm.Update(stageFailedMsg{Name: "B", Err: errB})
+	mm = updated.(Model)
+	if mm.awaiting != "A" {
+		t.Fatalf("awaiting changed to %q after a second concurrent failure, want it to stay %q", mm.awaiting, "A")
+	}
+	if len(mm.pendingFailures) != 1 || mm.pendingFailures[0] != "B" {
+		t.Fatalf("pendingFailures = %v, want [B]", mm.pendingFailures)
+	}
+
+	// Resolve A via retry; since Action succeeds, driving the returned cmd
+	// produces a stageCompleteMsg for A.
+	updated, cmd := mm.Update(keyRune('r'))
+	mm = updated.(Model)
+	if mm.awaiting != "B" {
+		t.Fatalf("awaiting = %q after retrying A, want the queued failure %q to take its turn", mm.awaiting, "B")
+	}
+	if cmd == nil {
+		t.Fatal("retry returned a nil cmd, want the re-run of stage A")
+	}
+	msg := runCmd(t, cmd)
+	completeA, ok := msg.(stageCompleteMsg)
+	if !ok || completeA.Name != "A" {
+		t.Fatalf("retry cmd produced %#v, want stageCompleteMsg{Name: \"A\"}", msg)
+	}
+
+	updated, _ = mm.Update(completeA)
+	mm = updated.(Model)
+	if mm.Error != nil {
+		t.Fatalf("mm.Error = %v, want nil while stage B is still Failed and unresolved", mm.Error)
+	}
+	if mm.allComplete() {
+		t.Fatal("allComplete() = true while stage B is still Failed")
+	}
+	if mm.awaiting != "B" {
+		t.Fatalf("awaiting = %q, want the still-unresolved failure %q to remain surfaced", mm.awaiting, "B")
+	}
+
+	// Skip B: every stage is now resolved, so the run should complete.
+	updated, cmd = mm.Update(keyRune('s'))
+	mm = updated.(Model)
+	if cmd == nil {
+		t.Fatal("skip returned a nil cmd once every stage was resolved, want tea.Quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatalf("skip cmd = %#v, want tea.QuitMsg", cmd())
+	}
+	if !mm.allComplete() {
+		t.Fatal("allComplete() = false after both stages were resolved via retry and skip")
+	}
+}
+
+func TestLogTickMsgRefreshesOpenViewport(t *testing.T) {
+	r := NewRunner([]Stage{
+		{Name: "A", Action: func(ctx context.Context, w io.Writer) error { return nil }},
+	})
+	m := NewModel(context.Background(), r)
+	m.focus = "A"
+	buf := &syncBuffer{}
+	r.setLog("A", buf)
+
+	sized, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = sized.(Model)
+
+	updated, cmd := m.Update(keyRune('l'))
+	mm := updated.(Model)
+	if !mm.showLogs {
+		t.Fatal("showLogs = false after pressing l")
+	}
+	if cmd == nil {
+		t.Fatal("opening the log viewport returned a nil cmd, want a logTickCmd")
+	}
+
+	buf.Write([]byte("first line\n"))
+	updated, cmd = mm.Update(cmd())
+	mm = updated.(Model)
+	if got := mm.logView.View(); !strings.Contains(got, "first line") {
+		t.Fatalf("log viewport = %q, want it to contain the buffer's new content after a tick", got)
+	}
+
+	buf.Write([]byte("second line\n"))
+	updated, _ = mm.Update(cmd())
+	mm = updated.(Model)
+	if got := mm.logView.View(); !strings.Contains(got, "second line") {
+		t.Fatalf("log viewport = %q, want it to contain content written after the viewport was opened", got)
+	}
+
+	mm.showLogs = false
+	updated, cmd = mm.Update(logTickMsg{})
+	mm = updated.(Model)
+	if mm.logTicking {
+		t.Fatal("logTicking stayed true after showLogs was turned off, want the tick chain to stop")
+	}
+	if cmd != nil {
+		t.Fatal("logTickMsg kept rescheduling itself after showLogs was turned off")
+	}
+}
+
+func TestModelDumpsLogOnD(t *testing.T) {
+	r := NewRunner([]Stage{
+		{Name: "A", Action: func(ctx context.Context, w io.Writer) error { return nil }},
+	})
+	m := NewModel(context.Background(), r)
+
+	buf := &syncBuffer{}
+	buf.Write([]byte("boom\n"))
+	r.setLog("A", buf)
+
+	updated, _ := m.Update(stageFailedMsg{Name: "A", Err: &StageError{Stage: "A"}})
+	mm := updated.(Model)
+
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	updated, _ = mm.Update(keyRune('d'))
+	mm = updated.(Model)
+
+	wantPath := filepath.Join(".", "A.log")
+	if _, err := os.Stat(wantPath); err != nil {
+		t.Fatalf("dumpLog didn't write %s: %v", wantPath, err)
+	}
+	if !strings.Contains(mm.dumpStatus, "A.log") {
+		t.Fatalf("dumpStatus = %q, want it to mention the written path", mm.dumpStatus)
+	}
+}
+
+func TestModelUndoRevertsStateAndReportsStatus(t *testing.T) {
+	r := NewRunner([]Stage{
+		{
+			Name:       "One",
+			IsComplete: true,
+			Reset:      func(ctx context.Context) error { return nil },
+		},
+		{Name: "Two", DependsOn: []string{"One"}},
+	})
+	r.setState("One", StageComplete)
+	m := NewModel(context.Background(), r)
+
+	updated, _ := m.Update(stageFailedMsg{Name: "Two", Err: &StageError{Stage: "Two"}})
+	mm := updated.(Model)
+	if mm.awaiting != "Two" {
+		t.Fatalf("awaiting = %q, want %q", mm.awaiting, "Two")
+	}
+
+	updated, _ = mm.Update(keyRune('u'))
+	mm = updated.(Model)
+
+	if got := mm.runner.State("One"); got != StagePending {
+		t.Fatalf("runner.State(One) = %v after undo, want StagePending", got)
+	}
+	if !strings.Contains(mm.undoStatus, "One") {
+		t.Fatalf("undoStatus = %q, want it to mention the rolled-back stage", mm.undoStatus)
+	}
+}
+
+func TestModelAutoOpensLogsOnFailure(t *testing.T) {
+	r := NewRunner([]Stage{
+		{Name: "A", Action: func(ctx context.Context, w io.Writer) error { return nil }},
+	})
+	m := NewModel(context.Background(), r)
+	if m.showLogs {
+		t.Fatal("showLogs = true before any stage failed")
+	}
+
+	updated, _ := m.Update(stageFailedMsg{Name: "A", Err: &StageError{Stage: "A"}})
+	mm := updated.(Model)
+	if !mm.showLogs {
+		t.Fatal("showLogs = false after a stage failed, want the viewport to auto-open")
+	}
+}
@@ -0,0 +1,83 @@
+package stages
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RendererMode selects how a Runner's progress is surfaced to the caller.
+type RendererMode int
+
+const (
+	// RendererAuto resolves to RendererTTY when the caller has a terminal
+	// and RendererPlain otherwise. Callers decide TTY-ness themselves
+	// (e.g. with golang.org/x/term.IsTerminal) and pass the result to
+	// RendererMode.Resolve; the stages package doesn't probe the terminal
+	// itself.
+	RendererAuto RendererMode = iota
+	RendererTTY
+	RendererPlain
+	RendererJSON
+)
+
+// Resolve collapses RendererAuto into RendererTTY or
+// RendererPlain based on isTTY, and passes every other mode through
+// unchanged.
+func (mode RendererMode) Resolve(isTTY bool) RendererMode {
+	if mode != RendererAuto {
+		return mode
+	}
+	if isTTY {
+		return RendererTTY
+	}
+	return RendererPlain
+}
+
+// StageEvent describes a single start/finish/fail transition of a stage.
+// It's what OnEvent receives, for callers that want to observe a Runner
+// without driving a Bubble Tea program - e.g. the Plain and JSON renderers
+// below.
+type StageEvent struct {
+	Stage     string
+	Event     string // "started", "finished", or "failed"
+	Err       error
+	ElapsedMS int64
+	Time      time.Time
+}
+
+// PlainRenderer returns an OnEvent func that prints one line per event to
+// w, with a timestamp and no ANSI escapes - suitable for CI logs or any
+// non-interactive pipe.
+func PlainRenderer(w io.Writer) func(StageEvent) {
+	return func(e StageEvent) {
+		ts := e.Time.UTC().Format(time.RFC3339)
+		if e.Event == "failed" {
+			fmt.Fprintf(w, "%s stage=%s event=failed elapsed_ms=%d err=%q\n", ts, e.Stage, e.ElapsedMS, e.Err)
+			return
+		}
+		fmt.Fprintf(w, "%s stage=%s event=%s elapsed_ms=%d\n", ts, e.Stage, e.Event, e.ElapsedMS)
+	}
+}
+
+// jsonEvent is the newline-delimited JSON shape emitted by JSONRenderer.
+type jsonEvent struct {
+	Stage     string `json:"stage"`
+	Event     string `json:"event"`
+	Err       string `json:"err,omitempty"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+}
+
+// JSONRenderer returns an OnEvent func that writes one JSON object per line
+// to w, suitable for log aggregation.
+func JSONRenderer(w io.Writer) func(StageEvent) {
+	enc := json.NewEncoder(w)
+	return func(e StageEvent) {
+		rec := jsonEvent{Stage: e.Stage, Event: e.Event, ElapsedMS: e.ElapsedMS}
+		if e.Err != nil {
+			rec.Err = e.Err.Error()
+		}
+		_ = enc.Encode(rec)
+	}
+}
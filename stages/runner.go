@@ -0,0 +1,297 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runner drives a pipeline of Stages to completion. Stages whose DependsOn
+// are all satisfied run concurrently; the rest of the graph is walked as
+// dependencies resolve.
+type Runner struct {
+	Stages []Stage
+
+	// Logs holds the captured output of each stage's Action, keyed by
+	// Stage.Name. It's populated as stages run, so a stage's entry is only
+	// present once that stage has started. Each buffer is internally
+	// synchronized, so it's safe to read via Log while the stage that owns
+	// it is still writing to it.
+	Logs map[string]*syncBuffer
+
+	logsMu sync.Mutex
+
+	statesMu sync.Mutex
+	states   map[string]StageState
+
+	// RendererMode selects how Run's progress should be surfaced. It's
+	// advisory: Run itself stays headless and only calls OnEvent, so
+	// callers resolve RendererMode (see RendererMode.Resolve) and wire up
+	// OnEvent - or build a Model for RendererTTY - accordingly.
+	RendererMode RendererMode
+
+	// OnEvent, if set, is called synchronously from Run for every stage
+	// start/finish/failure. See PlainRenderer and JSONRenderer for ready
+	// made implementations.
+	OnEvent func(StageEvent)
+}
+
+// NewRunner builds a Runner over the given stages.
+func NewRunner(stages []Stage) *Runner {
+	states := make(map[string]StageState, len(stages))
+	for _, s := range stages {
+		states[s.Name] = StagePending
+	}
+	return &Runner{
+		Stages: stages,
+		Logs:   make(map[string]*syncBuffer, len(stages)),
+		states: states,
+	}
+}
+
+// State returns the current StageState of the named stage.
+func (r *Runner) State(name string) StageState {
+	r.statesMu.Lock()
+	defer r.statesMu.Unlock()
+	return r.states[name]
+}
+
+// States returns a snapshot of every stage's current StageState, keyed by
+// name.
+func (r *Runner) States() map[string]StageState {
+	r.statesMu.Lock()
+	defer r.statesMu.Unlock()
+	out := make(map[string]StageState, len(r.states))
+	for k, v := range r.states {
+		out[k] = v
+	}
+	return out
+}
+
+func (r *Runner) setState(name string, s StageState) {
+	r.statesMu.Lock()
+	r.states[name] = s
+	r.statesMu.Unlock()
+}
+
+// Log returns the captured log buffer for the named stage, or nil if the
+// stage hasn't started yet.
+func (r *Runner) Log(name string) *syncBuffer {
+	r.logsMu.Lock()
+	defer r.logsMu.Unlock()
+	return r.Logs[name]
+}
+
+func (r *Runner) setLog(name string, buf *syncBuffer) {
+	r.logsMu.Lock()
+	r.Logs[name] = buf
+	r.logsMu.Unlock()
+}
+
+func (r *Runner) stageByName(name string) *Stage {
+	for i := range r.Stages {
+		if r.Stages[i].Name == name {
+			return &r.Stages[i]
+		}
+	}
+	return nil
+}
+
+// indexOf returns the declaration-order index of the named stage, or -1 if
+// it isn't found.
+func (r *Runner) indexOf(name string) int {
+	for i := range r.Stages {
+		if r.Stages[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Runner) emit(stage string, event string, err error, elapsed time.Duration) {
+	if r.OnEvent == nil {
+		return
+	}
+	r.OnEvent(StageEvent{
+		Stage:     stage,
+		Event:     event,
+		Err:       err,
+		ElapsedMS: elapsed.Milliseconds(),
+		Time:      time.Now(),
+	})
+}
+
+// validate checks the stage graph for problems that would otherwise leave
+// Run or an interactive Model waiting forever with nothing left to cancel
+// it, or corrupt the per-stage bookkeeping Run relies on: a Stage.Name used
+// more than once, a DependsOn naming a stage that doesn't exist, or a
+// dependency cycle.
+func (r *Runner) validate() error {
+	names := make(map[string]bool, len(r.Stages))
+	for _, s := range r.Stages {
+		if names[s.Name] {
+			return fmt.Errorf("stages: duplicate stage name %q", s.Name)
+		}
+		names[s.Name] = true
+	}
+	for _, s := range r.Stages {
+		for _, dep := range s.DependsOn {
+			if !names[dep] {
+				return fmt.Errorf("stages: stage %q depends on unknown stage %q", s.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(r.Stages))
+	var walk func(name string, path []string) error
+	walk = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("stages: dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, dep := range r.stageByName(name).DependsOn {
+			if err := walk(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, s := range r.Stages {
+		if err := walk(s.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run walks the stage DAG, running stages with satisfied DependsOn
+// concurrently. If any stage fails, in-flight stages are cancelled via ctx,
+// every completed stage is rolled back (in reverse declaration order) by
+// calling its Reset func, and the stage failures are returned joined
+// together with errors.Join.
+func (r *Runner) Run(ctx context.Context) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(map[string]chan struct{}, len(r.Stages))
+	for _, s := range r.Stages {
+		done[s.Name] = make(chan struct{})
+	}
+
+	var (
+		wg     sync.WaitGroup
+		errsMu sync.Mutex
+		errs   []error
+	)
+
+	for i := range r.Stages {
+		stage := &r.Stages[i]
+		wg.Add(1)
+		go func(index int, stage *Stage) {
+			defer wg.Done()
+			defer close(done[stage.Name])
+
+			for _, dep := range stage.DependsOn {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			r.setState(stage.Name, StageRunning)
+			r.emit(stage.Name, "started", nil, 0)
+
+			if stage.IsCompleteFunc != nil && stage.IsCompleteFunc() {
+				stage.IsComplete = true
+				r.setState(stage.Name, StageComplete)
+				r.emit(stage.Name, "finished", nil, 0)
+				return
+			}
+
+			buf := &syncBuffer{}
+			r.setLog(stage.Name, buf)
+
+			stageCtx := ctx
+			stageCancel := func() {}
+			if stage.Timeout > 0 {
+				stageCtx, stageCancel = context.WithTimeout(ctx, stage.Timeout)
+			}
+			start := time.Now()
+			err := stage.Action(stageCtx, buf)
+			elapsed := time.Since(start)
+			stageCancel()
+
+			if err != nil {
+				stageErr := newStageError(stage.Name, index, elapsed, tailLines(buf.String(), 20), err)
+				stage.Error = stageErr
+				r.setState(stage.Name, StageFailed)
+				r.emit(stage.Name, "failed", stageErr, elapsed)
+				errsMu.Lock()
+				errs = append(errs, stageErr)
+				errsMu.Unlock()
+				cancel()
+				return
+			}
+
+			stage.IsComplete = true
+			r.setState(stage.Name, StageComplete)
+			r.emit(stage.Name, "finished", nil, elapsed)
+		}(i, stage)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		r.rollback(context.Background())
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// rollback calls Reset on every completed stage, in reverse declaration
+// order, undoing the work done so far, and returns the names of the stages
+// it rolled back (in that same reverse order). A stage's IsComplete is
+// cleared once its Reset has run, so calling rollback again - e.g. pressing
+// 'u' more than once in an interactive Model - won't invoke Reset a second
+// time for the same stage.
+//
+// Each rolled-back stage's StageState reverts to StagePending, so the
+// scheduler (readyStages/dispatchReady/allComplete) no longer mistakes it
+// for done: the run can't report success again until the stage is actually
+// redispatched and completes. Rollback deliberately re-dispatches rather
+// than aborting the whole run - the same as retrying or skipping a single
+// failure, undo resumes the pipeline once the stage currently awaiting a
+// decision is resolved, instead of forcing a quit.
+func (r *Runner) rollback(ctx context.Context) []string {
+	var rolledBack []string
+	for i := len(r.Stages) - 1; i >= 0; i-- {
+		stage := &r.Stages[i]
+		if stage.IsComplete && stage.Reset != nil {
+			_ = stage.Reset(ctx)
+			stage.IsComplete = false
+			r.setState(stage.Name, StagePending)
+			rolledBack = append(rolledBack, stage.Name)
+		}
+	}
+	return rolledBack
+}
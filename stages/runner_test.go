@@ -0,0 +1,150 @@
+package stages
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// noopStage returns a Stage that succeeds immediately, used where only the
+// graph shape under test matters.
+func noopStage(name string, deps ...string) Stage {
+	return Stage{
+		Name:      name,
+		DependsOn: deps,
+		Action: func(ctx context.Context, w io.Writer) error {
+			return nil
+		},
+	}
+}
+
+func TestRunRejectsUnknownDependency(t *testing.T) {
+	r := NewRunner([]Stage{
+		noopStage("A", "DoesNotExist"),
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for an unknown DependsOn name, got nil")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run hung instead of rejecting an unknown DependsOn name")
+	}
+}
+
+func TestRunRejectsDependencyCycle(t *testing.T) {
+	r := NewRunner([]Stage{
+		noopStage("A", "B"),
+		noopStage("B", "A"),
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a dependency cycle, got nil")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run hung instead of rejecting a dependency cycle")
+	}
+}
+
+func TestRunRejectsDuplicateStageName(t *testing.T) {
+	r := NewRunner([]Stage{
+		noopStage("A"),
+		noopStage("A"),
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a duplicate stage name, got nil")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run hung or panicked instead of rejecting a duplicate stage name")
+	}
+}
+
+func TestRunAcceptsValidGraph(t *testing.T) {
+	r := NewRunner([]Stage{
+		noopStage("A"),
+		noopStage("B", "A"),
+	})
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run on a valid graph returned an error: %v", err)
+	}
+}
+
+func TestRollbackDoesNotDoubleInvokeReset(t *testing.T) {
+	resets := 0
+	r := NewRunner([]Stage{
+		{
+			Name:       "A",
+			IsComplete: true,
+			Reset: func(ctx context.Context) error {
+				resets++
+				return nil
+			},
+		},
+	})
+
+	r.rollback(context.Background())
+	r.rollback(context.Background())
+
+	if resets != 1 {
+		t.Fatalf("Reset invoked %d times across two rollback calls, want 1", resets)
+	}
+}
+
+func TestRollbackRevertsStateToPending(t *testing.T) {
+	r := NewRunner([]Stage{
+		{
+			Name:       "A",
+			IsComplete: true,
+			Reset:      func(ctx context.Context) error { return nil },
+		},
+	})
+	r.setState("A", StageComplete)
+
+	rolledBack := r.rollback(context.Background())
+
+	if len(rolledBack) != 1 || rolledBack[0] != "A" {
+		t.Fatalf("rollback returned %v, want [A]", rolledBack)
+	}
+	if got := r.State("A"); got != StagePending {
+		t.Fatalf("State(A) = %v after rollback, want StagePending", got)
+	}
+}
+
+// TestSyncBufferConcurrentWriteAndRead reproduces the log-viewport race: a
+// stage's Action writes into its buffer from its own goroutine while
+// another goroutine (an interactive Model's log viewport) reads it. Run
+// with -race to catch a regression.
+func TestSyncBufferConcurrentWriteAndRead(t *testing.T) {
+	buf := &syncBuffer{}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			buf.Write([]byte("line\n"))
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		_ = buf.String()
+		_ = buf.Bytes()
+	}
+	<-done
+}
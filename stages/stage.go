@@ -0,0 +1,44 @@
+package stages
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Stage is a single step in a Runner's pipeline. Stages whose DependsOn are
+// all complete may run at the same time, and the Runner stops advancing if a
+// stage's Action returns an error.
+type Stage struct {
+	Name string
+
+	// Action performs the stage's work. The io.Writer is a per-stage log
+	// sink: anything written to it is captured in the Runner's Logs for
+	// that stage name, so callers can surface progress without reaching
+	// for a package-level logger.
+	Action func(ctx context.Context, w io.Writer) error
+
+	// DependsOn names the stages that must complete before this one may
+	// start. Stages with no unmet dependencies are dispatched concurrently,
+	// so an empty DependsOn on every stage starts all stages at once; to run
+	// stages one after another, make each depend on the one before it.
+	DependsOn []string
+
+	// Timeout bounds how long Action is allowed to run. Zero means no
+	// per-stage deadline is applied beyond whatever the caller's ctx carries.
+	Timeout time.Duration
+
+	// Reset undoes the work performed by Action. When a later stage fails,
+	// the Runner calls Reset on every completed stage, in reverse order, so
+	// the pipeline can be rolled back to where it started.
+	Reset func(ctx context.Context) error
+
+	// SkipGuard, if set, is consulted when an interactive Model is asked to
+	// skip this stage past a failure. A non-nil return vetoes the skip and
+	// is shown to the user instead.
+	SkipGuard func() error
+
+	Error          error
+	IsComplete     bool
+	IsCompleteFunc func() bool
+}
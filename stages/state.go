@@ -0,0 +1,14 @@
+package stages
+
+// StageState describes where a stage is in its lifecycle under a Runner's
+// concurrent scheduler.
+type StageState int
+
+const (
+	// StagePending stages haven't started; they're either waiting on
+	// DependsOn stages to complete or haven't been scheduled yet.
+	StagePending StageState = iota
+	StageRunning
+	StageComplete
+	StageFailed
+)